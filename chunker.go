@@ -0,0 +1,102 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+)
+
+// Content-defined chunking parameters. Chunk boundaries are picked by a
+// rolling hash rather than fixed offsets so that inserting or changing a
+// few bytes near the start of a file only reshuffles the chunks around
+// the edit instead of every chunk after it.
+const (
+	minChunkSize = 512 * 1024
+	avgChunkSize = 1024 * 1024
+	maxChunkSize = 4 * 1024 * 1024
+	windowSize   = 64
+
+	// cutMask keeps the low log2(avgChunkSize/minChunkSize)-ish bits of
+	// the rolling hash at zero on average once every avgChunkSize bytes.
+	cutMask = avgChunkSize - 1
+)
+
+// buzhash is a cyclic-polynomial rolling hash: each byte maps to a
+// pseudo-random 32-bit value via a table, and the hash is updated by
+// rotating out the byte that left the window and rotating in the new
+// one. It's cheap per byte and has no multiplications, which is why
+// restic and similar chunkers use it over Rabin fingerprints.
+var buzhashTable = func() [256]uint32 {
+	var table [256]uint32
+	seed := uint32(0x9e3779b9)
+	for i := range table {
+		seed ^= seed << 13
+		seed ^= seed >> 17
+		seed ^= seed << 5
+		table[i] = seed*uint32(i+1) + seed
+	}
+	return table
+}()
+
+func rotl32(x uint32, n uint) uint32 {
+	return x<<n | x>>(32-n)
+}
+
+// Chunk describes one content-defined slice of a file.
+type Chunk struct {
+	Index  int
+	Offset int64
+	Length int64
+	SHA256 string
+}
+
+// splitChunks reads all of r and cuts it into content-defined chunks,
+// returning their boundaries and hashes alongside the full data (callers
+// that only need the manifest can discard the data).
+func splitChunks(r io.Reader) ([]Chunk, []byte, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var chunks []Chunk
+	start := 0
+	var hash uint32
+	var window []byte
+
+	flush := func(end int) {
+		sum := sha256.Sum256(data[start:end])
+		chunks = append(chunks, Chunk{
+			Index:  len(chunks),
+			Offset: int64(start),
+			Length: int64(end - start),
+			SHA256: hex.EncodeToString(sum[:]),
+		})
+		start = end
+		hash = 0
+		window = window[:0]
+	}
+
+	for i := 0; i < len(data); i++ {
+		b := data[i]
+		window = append(window, b)
+		if len(window) > windowSize {
+			dropped := window[0]
+			window = window[1:]
+			hash ^= rotl32(buzhashTable[dropped], uint(len(window)))
+		}
+		hash = rotl32(hash, 1) ^ buzhashTable[b]
+
+		size := i + 1 - start
+		atCutPoint := size >= minChunkSize && hash&cutMask == 0
+		if atCutPoint || size >= maxChunkSize {
+			flush(i + 1)
+		}
+	}
+
+	if start < len(data) {
+		flush(len(data))
+	}
+
+	return chunks, data, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/rand"
+	"testing"
+)
+
+func TestSplitChunksReassembles(t *testing.T) {
+	data := make([]byte, 3*maxChunkSize)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	chunks, got, err := splitChunks(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("splitChunks failed: %v", err)
+	}
+	if len(chunks) < 2 {
+		t.Fatalf("expected multiple chunks for %d bytes, got %d", len(data), len(chunks))
+	}
+
+	var rebuilt []byte
+	for _, c := range chunks {
+		piece := got[c.Offset : c.Offset+c.Length]
+		sum := sha256.Sum256(piece)
+		if hex.EncodeToString(sum[:]) != c.SHA256 {
+			t.Fatalf("chunk %d hash mismatch", c.Index)
+		}
+		rebuilt = append(rebuilt, piece...)
+	}
+
+	if !bytes.Equal(rebuilt, data) {
+		t.Fatal("reassembled data does not match original")
+	}
+}
+
+func TestSplitChunksStableAroundInsertion(t *testing.T) {
+	base := make([]byte, 2*maxChunkSize)
+	rand.New(rand.NewSource(2)).Read(base)
+
+	original, _, err := splitChunks(bytes.NewReader(base))
+	if err != nil {
+		t.Fatalf("splitChunks failed: %v", err)
+	}
+
+	// Insert a few bytes well past the first chunk boundary; chunks
+	// entirely before the insertion point should keep the same hashes.
+	insertAt := len(base) / 2
+	modified := append([]byte{}, base[:insertAt]...)
+	modified = append(modified, []byte("EXTRA")...)
+	modified = append(modified, base[insertAt:]...)
+
+	changed, _, err := splitChunks(bytes.NewReader(modified))
+	if err != nil {
+		t.Fatalf("splitChunks failed: %v", err)
+	}
+
+	matched := 0
+	for _, c := range original {
+		if c.Offset+c.Length > int64(insertAt) {
+			break
+		}
+		if c.Index < len(changed) && changed[c.Index].SHA256 == c.SHA256 {
+			matched++
+		}
+	}
+
+	if matched == 0 {
+		t.Fatal("expected at least one chunk before the insertion point to be unchanged")
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// sendFile sends path as a framed OpPut request on w. preferred selects
+// the compression codec to try; chooseCompression may still skip it for
+// extensions that are already compressed. The header carries the
+// original (uncompressed) size and crc32 so the server can verify the
+// transfer after decompressing it.
+func sendFile(w io.Writer, path string, preferred CompressionType) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("stat %s: %w", path, err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	compression := chooseCompression(path, preferred)
+
+	var body bytes.Buffer
+	compressor, err := newCompressWriter(&body, compression)
+	if err != nil {
+		return fmt.Errorf("create compressor: %w", err)
+	}
+
+	crc := crc32.NewIEEE()
+	if _, err := io.Copy(io.MultiWriter(compressor, crc), f); err != nil {
+		return fmt.Errorf("compress %s: %w", path, err)
+	}
+	if err := compressor.Close(); err != nil {
+		return fmt.Errorf("flush compressor for %s: %w", path, err)
+	}
+
+	header := FrameHeader{
+		Filename:     filepath.Base(path),
+		Size:         info.Size(),
+		ModTime:      info.ModTime(),
+		Mode:         uint32(info.Mode().Perm()),
+		Compression:  compression,
+		OriginalSize: info.Size(),
+		CRC32:        crc.Sum32(),
+	}
+
+	if err := writeFrame(w, OpPut, header); err != nil {
+		return fmt.Errorf("write put header for %s: %w", path, err)
+	}
+	if _, err := w.Write(body.Bytes()); err != nil {
+		return fmt.Errorf("send %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// incompressibleExtensions already carry their own compression (archives,
+// video, most images), so spending CPU on zstd/gzip for them would only
+// add latency for no size win.
+var incompressibleExtensions = map[string]bool{
+	".zip": true, ".gz": true, ".xz": true, ".7z": true, ".rar": true,
+	".mp4": true, ".mkv": true, ".mov": true, ".avi": true,
+	".jpg": true, ".jpeg": true, ".png": true, ".webp": true, ".gif": true,
+	".mp3": true, ".flac": true, ".ogg": true,
+}
+
+// chooseCompression picks a codec for filename given the client's
+// requested preference, skipping compression entirely for extensions
+// that are already compressed.
+func chooseCompression(filename string, preferred CompressionType) CompressionType {
+	if preferred == CompressionNone {
+		return CompressionNone
+	}
+	if incompressibleExtensions[strings.ToLower(filepath.Ext(filename))] {
+		return CompressionNone
+	}
+	return preferred
+}
+
+// newCompressWriter wraps w so that bytes written to the result are
+// compressed with the given codec before reaching w. Callers must Close
+// the result to flush trailing codec state.
+func newCompressWriter(w io.Writer, compression CompressionType) (io.WriteCloser, error) {
+	switch compression {
+	case CompressionNone, "":
+		return nopWriteCloser{w}, nil
+	case CompressionZstd:
+		return zstd.NewWriter(w)
+	case CompressionGzip:
+		return gzip.NewWriter(w), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", compression)
+	}
+}
+
+// newDecompressReader wraps r so that reads from the result yield the
+// decompressed bytes of the given codec.
+func newDecompressReader(r io.Reader, compression CompressionType) (io.Reader, error) {
+	switch compression {
+	case CompressionNone, "":
+		return r, nil
+	case CompressionZstd:
+		dec, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("create zstd reader: %w", err)
+		}
+		return dec.IOReadCloser(), nil
+	case CompressionGzip:
+		return gzip.NewReader(r)
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", compression)
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
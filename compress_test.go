@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestChooseCompressionSkipsIncompressibleExtensions(t *testing.T) {
+	if got := chooseCompression("movie.mp4", CompressionZstd); got != CompressionNone {
+		t.Errorf("expected .mp4 to skip compression, got %q", got)
+	}
+	if got := chooseCompression("report.txt", CompressionZstd); got != CompressionZstd {
+		t.Errorf("expected .txt to keep zstd, got %q", got)
+	}
+	if got := chooseCompression("report.txt", CompressionNone); got != CompressionNone {
+		t.Errorf("expected no-preference to stay none, got %q", got)
+	}
+}
+
+func TestCompressRoundTrip(t *testing.T) {
+	original := []byte(strings.Repeat("the quick brown fox jumps over the lazy dog ", 1000))
+
+	for _, compression := range []CompressionType{CompressionNone, CompressionGzip, CompressionZstd} {
+		var compressed bytes.Buffer
+		w, err := newCompressWriter(&compressed, compression)
+		if err != nil {
+			t.Fatalf("%s: newCompressWriter failed: %v", compression, err)
+		}
+		if _, err := w.Write(original); err != nil {
+			t.Fatalf("%s: write failed: %v", compression, err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("%s: close failed: %v", compression, err)
+		}
+
+		r, err := newDecompressReader(&compressed, compression)
+		if err != nil {
+			t.Fatalf("%s: newDecompressReader failed: %v", compression, err)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("%s: read failed: %v", compression, err)
+		}
+
+		if !bytes.Equal(got, original) {
+			t.Errorf("%s: round trip mismatch", compression)
+		}
+	}
+}
+
+// TestSendFilePutRoundTrip drives a highly compressible file through
+// sendFile and the framed OpPut handler end to end, the integration
+// path TestCompressRoundTrip doesn't cover since it only exercises the
+// codec in isolation.
+func TestSendFilePutRoundTrip(t *testing.T) {
+	for _, compression := range []CompressionType{CompressionNone, CompressionGzip, CompressionZstd} {
+		t.Run(string(compression), func(t *testing.T) {
+			withOutDir(t, t.TempDir())
+
+			srcDir := t.TempDir()
+			srcPath := filepath.Join(srcDir, "report.txt")
+			content := []byte(strings.Repeat("the quick brown fox jumps over the lazy dog ", 1000))
+			if err := os.WriteFile(srcPath, content, 0644); err != nil {
+				t.Fatalf("write source file: %v", err)
+			}
+
+			var wire bytes.Buffer
+			if err := sendFile(&wire, srcPath, compression); err != nil {
+				t.Fatalf("sendFile failed: %v", err)
+			}
+
+			reader := bufio.NewReader(&wire)
+			framed, err := peekMagic(reader)
+			if err != nil || !framed {
+				t.Fatalf("expected a framed request, framed=%v err=%v", framed, err)
+			}
+			if _, err := reader.Discard(len(protocolMagic)); err != nil {
+				t.Fatalf("discard magic: %v", err)
+			}
+			op, header, err := readFrame(reader)
+			if err != nil {
+				t.Fatalf("readFrame failed: %v", err)
+			}
+			if op != OpPut {
+				t.Fatalf("expected OpPut, got %v", op)
+			}
+			if header.OriginalSize != int64(len(content)) {
+				t.Errorf("expected OriginalSize %d, got %d", len(content), header.OriginalSize)
+			}
+
+			if err := handlePut(&bufferedStream{ReadWriter: &wire, r: reader}, header); err != nil {
+				t.Fatalf("handlePut failed: %v", err)
+			}
+
+			entries, err := filepath.Glob(filepath.Join(outDir, "report.txt_*.txt"))
+			if err != nil {
+				t.Fatalf("glob failed: %v", err)
+			}
+			if len(entries) != 1 {
+				t.Fatalf("expected exactly one received file, got %v", entries)
+			}
+
+			got, err := os.ReadFile(entries[0])
+			if err != nil {
+				t.Fatalf("read received file: %v", err)
+			}
+			if !bytes.Equal(got, content) {
+				t.Error("received content does not match source after round trip")
+			}
+		})
+	}
+}
+
+// BenchmarkCompressionThroughput demonstrates the win zstd gives over
+// sending a highly compressible payload uncompressed, the same kind of
+// file (repetitive logs, source dumps) this is meant to speed up.
+func BenchmarkCompressionThroughput(b *testing.B) {
+	payload := bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog\n"), 50000)
+
+	for _, compression := range []CompressionType{CompressionNone, CompressionGzip, CompressionZstd} {
+		compression := compression
+		b.Run(string(compression), func(b *testing.B) {
+			b.SetBytes(int64(len(payload)))
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				w, err := newCompressWriter(io.Discard, compression)
+				if err != nil {
+					b.Fatalf("newCompressWriter failed: %v", err)
+				}
+				if _, err := w.Write(payload); err != nil {
+					b.Fatalf("write failed: %v", err)
+				}
+				if err := w.Close(); err != nil {
+					b.Fatalf("close failed: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Config is the server's on-disk configuration, loaded from --config or
+// $QFT_CONFIG. It only covers settings that make sense to pin across
+// restarts; --code and --out stay CLI/env flags since they're meant to
+// change per run.
+type Config struct {
+	TLS TLSInfo `toml:"tls"`
+}
+
+// loadConfig reads and decodes a TOML config file. An empty path is not
+// an error: it just means the caller runs with zero-value defaults.
+func loadConfig(path string) (Config, error) {
+	var cfg Config
+	if path == "" {
+		return cfg, nil
+	}
+
+	if _, err := toml.DecodeFile(path, &cfg); err != nil {
+		return Config{}, fmt.Errorf("decode config %s: %w", path, err)
+	}
+	return cfg, nil
+}
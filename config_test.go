@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfigDecodesTLSSection(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "qft.toml")
+	contents := `
+[tls]
+cert_file = "/etc/qft/cert.pem"
+key_file = "/etc/qft/key.pem"
+trusted_ca_file = "/etc/qft/ca.pem"
+client_cert_auth = true
+server_name = "qft.example.com"
+`
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+
+	want := TLSInfo{
+		CertFile:       "/etc/qft/cert.pem",
+		KeyFile:        "/etc/qft/key.pem",
+		TrustedCAFile:  "/etc/qft/ca.pem",
+		ClientCertAuth: true,
+		ServerName:     "qft.example.com",
+	}
+	if cfg.TLS != want {
+		t.Errorf("expected %+v, got %+v", want, cfg.TLS)
+	}
+}
+
+func TestLoadConfigEmptyPathReturnsZeroValue(t *testing.T) {
+	cfg, err := loadConfig("")
+	if err != nil {
+		t.Fatalf("loadConfig(\"\"): %v", err)
+	}
+	if cfg != (Config{}) {
+		t.Errorf("expected zero-value Config, got %+v", cfg)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DirEntry describes one file, directory or symlink inside a manifest
+// sent for a directory transfer. RelPath is always slash-separated and
+// relative to the root being transferred, which is what lets filenames
+// longer than 255 bytes and nested paths travel in a single message
+// instead of the old 1-byte length prefix.
+type DirEntry struct {
+	RelPath       string    `json:"rel_path"`
+	Size          int64     `json:"size"`
+	Mode          uint32    `json:"mode"`
+	ModTime       time.Time `json:"mtime"`
+	IsDir         bool      `json:"is_dir"`
+	SymlinkTarget string    `json:"symlink_target,omitempty"`
+}
+
+// DirManifest is the first framed message of a directory transfer; file
+// bodies follow afterwards, each prefixed by its index into Entries.
+type DirManifest struct {
+	Entries []DirEntry `json:"entries"`
+}
+
+// buildManifest walks root and produces a DirManifest with slash-style
+// paths relative to it, ready to hand to writeJSON.
+func buildManifest(root string) (DirManifest, error) {
+	var manifest DirManifest
+
+	err := filepath.Walk(root, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return fmt.Errorf("relativize %s: %w", path, err)
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		entry := DirEntry{
+			RelPath: relPath,
+			Mode:    uint32(info.Mode().Perm()),
+			ModTime: info.ModTime(),
+			IsDir:   info.IsDir(),
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Readlink(path)
+			if err != nil {
+				return fmt.Errorf("read symlink %s: %w", path, err)
+			}
+			entry.SymlinkTarget = target
+		} else if !info.IsDir() {
+			entry.Size = info.Size()
+		}
+
+		manifest.Entries = append(manifest.Entries, entry)
+		return nil
+	})
+	if err != nil {
+		return DirManifest{}, fmt.Errorf("walk %s: %w", root, err)
+	}
+
+	return manifest, nil
+}
+
+// sendDirectoryTree walks root, sends it as an OpPutTree frame followed
+// by the manifest, then streams every regular file's bytes back-to-back,
+// each prefixed by its index into the manifest.
+func sendDirectoryTree(w io.Writer, root string) error {
+	manifest, err := buildManifest(root)
+	if err != nil {
+		return err
+	}
+
+	if err := writeFrame(w, OpPutTree, FrameHeader{Filename: filepath.Base(root)}); err != nil {
+		return fmt.Errorf("write tree header: %w", err)
+	}
+	if err := writeJSON(w, manifest); err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+
+	for i, entry := range manifest.Entries {
+		if entry.IsDir || entry.SymlinkTarget != "" {
+			continue
+		}
+
+		indexBuf := make([]byte, binary.MaxVarintLen64)
+		n := binary.PutUvarint(indexBuf, uint64(i))
+		if _, err := w.Write(indexBuf[:n]); err != nil {
+			return fmt.Errorf("write file index for %s: %w", entry.RelPath, err)
+		}
+
+		file, err := os.Open(filepath.Join(root, entry.RelPath))
+		if err != nil {
+			return fmt.Errorf("open %s: %w", entry.RelPath, err)
+		}
+		_, err = io.Copy(w, file)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("send %s: %w", entry.RelPath, err)
+		}
+	}
+
+	return nil
+}
+
+// resolveSafePath joins relPath onto outDir and rejects anything that
+// would land outside outDir once cleaned: absolute paths, "..", and
+// (via the caller checking SymlinkTarget separately) symlinks that
+// point outside the tree.
+func resolveSafePath(outDir, relPath string) (string, error) {
+	if filepath.IsAbs(relPath) {
+		return "", fmt.Errorf("rejecting absolute path %q", relPath)
+	}
+
+	cleaned := filepath.Clean(filepath.FromSlash(relPath))
+	if cleaned == "." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) || cleaned == ".." {
+		return "", fmt.Errorf("rejecting path escaping root: %q", relPath)
+	}
+
+	full := filepath.Join(outDir, cleaned)
+	outDirAbs, err := filepath.Abs(outDir)
+	if err != nil {
+		return "", fmt.Errorf("resolve out dir: %w", err)
+	}
+	fullAbs, err := filepath.Abs(full)
+	if err != nil {
+		return "", fmt.Errorf("resolve path: %w", err)
+	}
+	if fullAbs != outDirAbs && !strings.HasPrefix(fullAbs, outDirAbs+string(filepath.Separator)) {
+		return "", fmt.Errorf("rejecting path escaping root: %q", relPath)
+	}
+
+	return full, nil
+}
+
+// handlePutTree receives a directory transfer: the manifest frame was
+// already parsed into header by the caller... actually the manifest
+// travels as a second JSON frame after the (mostly empty) FrameHeader,
+// so it's read here before any file bodies.
+func handlePutTree(stream io.ReadWriter, header *FrameHeader) error {
+	reader := bufio.NewReader(stream)
+
+	var manifest DirManifest
+	if err := readJSON(reader, &manifest); err != nil {
+		return fmt.Errorf("read directory manifest: %w", err)
+	}
+
+	fileCreationMutex.Lock()
+	defer fileCreationMutex.Unlock()
+
+	destinations := make([]string, len(manifest.Entries))
+	var fileIndexes []int
+
+	for i, entry := range manifest.Entries {
+		dest, err := resolveSafePath(outDir, entry.RelPath)
+		if err != nil {
+			return err
+		}
+		destinations[i] = dest
+
+		switch {
+		case entry.IsDir:
+			if err := os.MkdirAll(dest, 0755); err != nil {
+				return fmt.Errorf("create directory %s: %w", entry.RelPath, err)
+			}
+		case entry.SymlinkTarget != "":
+			if filepath.IsAbs(entry.SymlinkTarget) {
+				return fmt.Errorf("rejecting absolute symlink target %q for %q", entry.SymlinkTarget, entry.RelPath)
+			}
+			if _, err := resolveSafePath(filepath.Dir(dest), entry.SymlinkTarget); err != nil {
+				return fmt.Errorf("rejecting symlink %q escaping root: %w", entry.RelPath, err)
+			}
+			if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+				return fmt.Errorf("create parent for symlink %s: %w", entry.RelPath, err)
+			}
+			if err := os.Symlink(entry.SymlinkTarget, dest); err != nil {
+				return fmt.Errorf("create symlink %s: %w", entry.RelPath, err)
+			}
+		default:
+			if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+				return fmt.Errorf("create parent for %s: %w", entry.RelPath, err)
+			}
+			fileIndexes = append(fileIndexes, i)
+		}
+	}
+
+	for range fileIndexes {
+		index, err := binary.ReadUvarint(reader)
+		if err != nil {
+			return fmt.Errorf("read file index: %w", err)
+		}
+		if int(index) >= len(manifest.Entries) {
+			return fmt.Errorf("file index %d out of range", index)
+		}
+
+		entry := manifest.Entries[index]
+		if err := receiveFileBody(reader, destinations[index], entry); err != nil {
+			return fmt.Errorf("receive %s: %w", entry.RelPath, err)
+		}
+	}
+
+	fmt.Println("Directory received successfully:", outDir)
+	return nil
+}
+
+func receiveFileBody(reader *bufio.Reader, dest string, entry DirEntry) error {
+	file, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(entry.Mode))
+	if err != nil {
+		return fmt.Errorf("create file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := io.CopyN(file, reader, entry.Size); err != nil {
+		return fmt.Errorf("copy body: %w", err)
+	}
+
+	if !entry.ModTime.IsZero() {
+		if err := os.Chtimes(dest, entry.ModTime, entry.ModTime); err != nil {
+			return fmt.Errorf("set mtime: %w", err)
+		}
+	}
+	return nil
+}
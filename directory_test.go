@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go"
+)
+
+// buildTestTree creates 3 directories and 10 files under a fresh temp
+// dir, with varied permissions so the receiving side's mode handling is
+// exercised too.
+func buildTestTree(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+
+	dirs := []string{"alpha", "alpha/beta", "gamma"}
+	for _, d := range dirs {
+		if err := os.MkdirAll(filepath.Join(root, d), 0755); err != nil {
+			t.Fatalf("mkdir %s: %v", d, err)
+		}
+	}
+
+	files := []struct {
+		path string
+		mode os.FileMode
+	}{
+		{"top.txt", 0644},
+		{"exec.sh", 0755},
+		{"alpha/one.txt", 0644},
+		{"alpha/two.txt", 0644},
+		{"alpha/beta/three.txt", 0644},
+		{"alpha/beta/four.txt", 0644},
+		{"alpha/beta/five.txt", 0644},
+		{"gamma/six.txt", 0644},
+		{"gamma/seven.txt", 0644},
+		{"gamma/eight.txt", 0644},
+	}
+
+	for i, f := range files {
+		content := fmt.Sprintf("content of file %d: %s", i, f.path)
+		if err := os.WriteFile(filepath.Join(root, f.path), []byte(content), f.mode); err != nil {
+			t.Fatalf("write %s: %v", f.path, err)
+		}
+	}
+
+	return root
+}
+
+// testOutDir returns the directory this suite expects received trees to
+// land in. It must match whatever --out/$QFT_OUT the server-under-test
+// was started with, the same way testCode must match its --code.
+func testOutDir() string {
+	if dir := os.Getenv("QFT_OUT"); dir != "" {
+		return dir
+	}
+	return "."
+}
+
+func TestDirectoryTransfer(t *testing.T) {
+	srcRoot := buildTestTree(t)
+	destRoot := testOutDir()
+
+	tlsConf := &tls.Config{
+		InsecureSkipVerify: true,
+		NextProtos:         []string{"quic-file-transfer"},
+	}
+	quicConfig := &quic.Config{MaxIdleTimeout: time.Second * 5}
+
+	conn, err := quic.DialAddr(context.Background(), "localhost:8080", tlsConf, quicConfig)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.CloseWithError(0, "")
+
+	stream, err := conn.OpenStreamSync(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	defer stream.Close()
+
+	aead, err := clientPAKEHandshake(stream, testCode())
+	if err != nil {
+		t.Fatalf("PAKE handshake failed: %v", err)
+	}
+	secure := newEncryptedStream(stream, aead)
+
+	if err := sendDirectoryTree(secure, srcRoot); err != nil {
+		t.Fatalf("sendDirectoryTree failed: %v", err)
+	}
+	if err := stream.Close(); err != nil {
+		t.Fatalf("Failed to close stream: %v", err)
+	}
+
+	time.Sleep(2 * time.Second)
+
+	srcManifest, err := buildManifest(srcRoot)
+	if err != nil {
+		t.Fatalf("buildManifest(src) failed: %v", err)
+	}
+
+	for _, entry := range srcManifest.Entries {
+		destPath := filepath.Join(destRoot, filepath.FromSlash(entry.RelPath))
+		defer os.RemoveAll(filepath.Join(destRoot, strings.SplitN(entry.RelPath, "/", 2)[0]))
+
+		info, err := os.Stat(destPath)
+		if err != nil {
+			t.Fatalf("expected %s to exist: %v", entry.RelPath, err)
+		}
+
+		if info.IsDir() != entry.IsDir {
+			t.Errorf("%s: expected IsDir=%v, got %v", entry.RelPath, entry.IsDir, info.IsDir())
+		}
+		if entry.IsDir {
+			continue
+		}
+
+		if info.Mode().Perm() != os.FileMode(entry.Mode) {
+			t.Errorf("%s: expected mode %v, got %v", entry.RelPath, os.FileMode(entry.Mode), info.Mode().Perm())
+		}
+
+		wantContent, err := os.ReadFile(filepath.Join(srcRoot, entry.RelPath))
+		if err != nil {
+			t.Fatalf("read source %s: %v", entry.RelPath, err)
+		}
+		gotContent, err := os.ReadFile(destPath)
+		if err != nil {
+			t.Fatalf("read dest %s: %v", entry.RelPath, err)
+		}
+		if string(gotContent) != string(wantContent) {
+			t.Errorf("%s: content mismatch", entry.RelPath)
+		}
+	}
+}
+
+func TestResolveSafePathRejectsEscape(t *testing.T) {
+	outDir := t.TempDir()
+
+	if _, err := resolveSafePath(outDir, "../escape.txt"); err == nil {
+		t.Error("expected \"../escape.txt\" to be rejected")
+	}
+	if _, err := resolveSafePath(outDir, "/etc/passwd"); err == nil {
+		t.Error("expected an absolute path to be rejected")
+	}
+
+	got, err := resolveSafePath(outDir, "nested/file.txt")
+	if err != nil {
+		t.Fatalf("expected nested relative path to be accepted: %v", err)
+	}
+	if filepath.Dir(got) != filepath.Join(outDir, "nested") {
+		t.Errorf("unexpected resolved path: %s", got)
+	}
+}
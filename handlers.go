@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/quic-go/quic-go"
+)
+
+// Handler serves one framed operation on an accepted (and, once
+// serverCode is set, already decrypted) stream. header is nil when the
+// stream turned out to be a legacy (pre-framing) request.
+type Handler func(rw io.ReadWriter, header *FrameHeader) error
+
+// handlers maps each op code to the function that serves it. New ops
+// register themselves here instead of the accept loop growing a switch.
+var handlers = map[OpCode]Handler{
+	OpPut:     handlePut,
+	OpGet:     handleGet,
+	OpList:    handleList,
+	OpStat:    handleStat,
+	OpResume:  handleResume,
+	OpPutTree: handlePutTree,
+}
+
+// serveStream authenticates the stream with the PAKE handshake unless
+// requireCode has been turned off, then dispatches it to the framed
+// handler for its op code, falling back to the legacy one-shot protocol
+// when the (decrypted, if applicable) stream doesn't start with the
+// magic at all.
+func serveStream(stream quic.Stream) {
+	var rw io.ReadWriter = stream
+	if requireCode {
+		aead, err := serverPAKEHandshake(stream, serverCode)
+		if err != nil {
+			log.Println("PAKE handshake failed:", err)
+			return
+		}
+		rw = newEncryptedStream(stream, aead)
+	}
+
+	reader := bufio.NewReader(rw)
+
+	framed, err := peekMagic(reader)
+	if err != nil {
+		log.Println("Failed to inspect stream:", err)
+		return
+	}
+
+	if !framed {
+		if err := handleLegacyPut(reader, rw); err != nil {
+			log.Println("Legacy transfer failed:", err)
+		}
+		return
+	}
+
+	if _, err := reader.Discard(len(protocolMagic)); err != nil {
+		log.Println("Failed to consume protocol magic:", err)
+		return
+	}
+
+	op, header, err := readFrame(reader)
+	if err != nil {
+		log.Println("Failed to read frame:", err)
+		return
+	}
+
+	handler, ok := handlers[op]
+	if !ok {
+		log.Println("Unsupported op code:", op)
+		return
+	}
+
+	if err := handler(&bufferedStream{ReadWriter: rw, r: reader}, header); err != nil {
+		log.Printf("%s handler failed: %v\n", op, err)
+	}
+}
+
+// bufferedStream lets handlers keep reading from the stream after the
+// accept loop has already buffered some of it via bufio.Reader.
+type bufferedStream struct {
+	io.ReadWriter
+	r *bufio.Reader
+}
+
+func (b *bufferedStream) Read(p []byte) (int, error) {
+	return b.r.Read(p)
+}
+
+func handlePut(stream io.ReadWriter, header *FrameHeader) error {
+	dest, err := resolveSafePath(outDir, header.Filename)
+	if err != nil {
+		return fmt.Errorf("resolve destination: %w", err)
+	}
+
+	fileCreationMutex.Lock()
+	defer fileCreationMutex.Unlock()
+
+	timestamp := time.Now().Format("20060102150405")
+	file, err := os.Create(dest + "_" + timestamp + ".txt")
+	if err != nil {
+		return fmt.Errorf("create file: %w", err)
+	}
+	defer file.Close()
+
+	body, err := newDecompressReader(stream, header.Compression)
+	if err != nil {
+		return fmt.Errorf("decompress body: %w", err)
+	}
+
+	crc := crc32.NewIEEE()
+	fmt.Println("Receiving file:", header.Filename)
+	written, err := io.Copy(io.MultiWriter(file, crc), body)
+	if err != nil {
+		return fmt.Errorf("receive file: %w", err)
+	}
+
+	if header.OriginalSize != 0 && written != header.OriginalSize {
+		return fmt.Errorf("decompressed size mismatch: expected %d bytes, got %d", header.OriginalSize, written)
+	}
+
+	if header.Compression != CompressionNone && header.Compression != "" && header.CRC32 != 0 && crc.Sum32() != header.CRC32 {
+		return fmt.Errorf("crc32 mismatch after decompression: expected %x, got %x", header.CRC32, crc.Sum32())
+	}
+
+	fmt.Println("File received successfully!")
+	return nil
+}
+
+func handleGet(stream io.ReadWriter, header *FrameHeader) error {
+	source, err := resolveSafePath(outDir, header.Filename)
+	if err != nil {
+		return fmt.Errorf("resolve source: %w", err)
+	}
+
+	file, err := os.Open(source)
+	if err != nil {
+		return fmt.Errorf("open file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(stream, file); err != nil {
+		return fmt.Errorf("send file: %w", err)
+	}
+	return nil
+}
+
+func handleList(stream io.ReadWriter, header *FrameHeader) error {
+	entries, err := os.ReadDir(outDir)
+	if err != nil {
+		return fmt.Errorf("read dir: %w", err)
+	}
+
+	for _, entry := range entries {
+		if _, err := fmt.Fprintln(stream, entry.Name()); err != nil {
+			return fmt.Errorf("write listing: %w", err)
+		}
+	}
+	return nil
+}
+
+func handleStat(stream io.ReadWriter, header *FrameHeader) error {
+	path, err := resolveSafePath(outDir, header.Filename)
+	if err != nil {
+		return fmt.Errorf("resolve path: %w", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("stat file: %w", err)
+	}
+
+	reply := FrameHeader{
+		Filename: header.Filename,
+		Size:     info.Size(),
+		ModTime:  info.ModTime(),
+		Mode:     uint32(info.Mode()),
+	}
+	return writeFrame(stream, OpStat, reply)
+}
+
+// handleLegacyPut reproduces the original ad-hoc protocol: a single
+// length byte, the filename, then the rest of the stream is the file.
+func handleLegacyPut(reader *bufio.Reader, stream io.ReadWriter) error {
+	filenameLen, err := reader.ReadByte()
+	if err != nil {
+		return fmt.Errorf("read filename length: %w", err)
+	}
+
+	filename := make([]byte, filenameLen)
+	if _, err := io.ReadFull(reader, filename); err != nil {
+		return fmt.Errorf("read filename: %w", err)
+	}
+
+	dest, err := resolveSafePath(outDir, string(filename))
+	if err != nil {
+		return fmt.Errorf("resolve destination: %w", err)
+	}
+
+	fileCreationMutex.Lock()
+	defer fileCreationMutex.Unlock()
+
+	timestamp := time.Now().Format("20060102150405")
+	file, err := os.Create(dest + "_" + timestamp + ".txt")
+	if err != nil {
+		return fmt.Errorf("create file: %w", err)
+	}
+	defer file.Close()
+
+	fmt.Println("Receiving file:", string(filename))
+	if _, err := io.Copy(file, reader); err != nil {
+		return fmt.Errorf("receive file: %w", err)
+	}
+
+	fmt.Println("File received successfully!")
+	return nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// withOutDir points the package-level outDir at dir for the duration of
+// the test, restoring the previous value afterwards.
+func withOutDir(t *testing.T, dir string) {
+	t.Helper()
+	prev := outDir
+	outDir = dir
+	t.Cleanup(func() { outDir = prev })
+}
+
+func TestHandlePutRejectsPathEscape(t *testing.T) {
+	withOutDir(t, t.TempDir())
+
+	stream := bytes.NewBufferString("malicious payload")
+	err := handlePut(stream, &FrameHeader{Filename: "../escape"})
+	if err == nil {
+		t.Fatal("expected handlePut to reject a path escaping outDir")
+	}
+}
+
+func TestHandlePutWritesUnderOutDir(t *testing.T) {
+	dir := t.TempDir()
+	withOutDir(t, dir)
+
+	stream := bytes.NewBufferString("hello world")
+	if err := handlePut(stream, &FrameHeader{Filename: "greeting"}); err != nil {
+		t.Fatalf("handlePut failed: %v", err)
+	}
+
+	entries, err := filepath.Glob(filepath.Join(dir, "greeting_*.txt"))
+	if err != nil {
+		t.Fatalf("glob failed: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected exactly one received file under outDir, got %v", entries)
+	}
+
+	got, err := os.ReadFile(entries[0])
+	if err != nil {
+		t.Fatalf("read received file: %v", err)
+	}
+	if string(got) != "hello world" {
+		t.Errorf("unexpected content: %q", got)
+	}
+}
+
+func TestHandleGetRejectsPathEscape(t *testing.T) {
+	withOutDir(t, t.TempDir())
+
+	var out bytes.Buffer
+	err := handleGet(&out, &FrameHeader{Filename: "../../etc/passwd"})
+	if err == nil {
+		t.Fatal("expected handleGet to reject a path escaping outDir")
+	}
+}
+
+func TestHandleListReadsFromOutDir(t *testing.T) {
+	dir := t.TempDir()
+	withOutDir(t, dir)
+
+	if err := os.WriteFile(filepath.Join(dir, "report.txt"), []byte("contents"), 0644); err != nil {
+		t.Fatalf("seed file: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := handleList(&out, &FrameHeader{}); err != nil {
+		t.Fatalf("handleList failed: %v", err)
+	}
+	if !bytes.Contains(out.Bytes(), []byte("report.txt")) {
+		t.Errorf("expected listing to contain report.txt, got %q", out.String())
+	}
+}
+
+// legacyRequest builds the raw bytes of an old-style request: a single
+// filename-length byte, the filename, then the body.
+func legacyRequest(filename, body string) *bufio.Reader {
+	var buf bytes.Buffer
+	buf.WriteByte(byte(len(filename)))
+	buf.WriteString(filename)
+	buf.WriteString(body)
+	return bufio.NewReader(&buf)
+}
+
+func TestHandleLegacyPutRejectsPathEscape(t *testing.T) {
+	withOutDir(t, t.TempDir())
+
+	reader := legacyRequest("../../../../tmp/evil", "malicious payload")
+	if err := handleLegacyPut(reader, &bytes.Buffer{}); err == nil {
+		t.Fatal("expected handleLegacyPut to reject a path escaping outDir")
+	}
+}
+
+func TestHandleLegacyPutWritesUnderOutDir(t *testing.T) {
+	dir := t.TempDir()
+	withOutDir(t, dir)
+
+	reader := legacyRequest("greeting", "hello world")
+	if err := handleLegacyPut(reader, &bytes.Buffer{}); err != nil {
+		t.Fatalf("handleLegacyPut failed: %v", err)
+	}
+
+	entries, err := filepath.Glob(filepath.Join(dir, "greeting_*.txt"))
+	if err != nil {
+		t.Fatalf("glob failed: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected exactly one received file under outDir, got %v", entries)
+	}
+
+	got, err := os.ReadFile(entries[0])
+	if err != nil {
+		t.Fatalf("read received file: %v", err)
+	}
+	if string(got) != "hello world" {
+		t.Errorf("unexpected content: %q", got)
+	}
+}
+
+func TestHandleGetReadsFromOutDir(t *testing.T) {
+	dir := t.TempDir()
+	withOutDir(t, dir)
+
+	if err := os.WriteFile(filepath.Join(dir, "report.txt"), []byte("contents"), 0644); err != nil {
+		t.Fatalf("seed file: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := handleGet(&out, &FrameHeader{Filename: "report.txt"}); err != nil {
+		t.Fatalf("handleGet failed: %v", err)
+	}
+	if out.String() != "contents" {
+		t.Errorf("unexpected content: %q", out.String())
+	}
+}
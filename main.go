@@ -2,27 +2,94 @@ package main
 
 import (
 	"context"
-	"crypto/rand"
-	"crypto/rsa"
 	"crypto/tls"
-	"crypto/x509"
-	"encoding/pem"
+	"flag"
 	"fmt"
-	"io"
 	"log"
-	"math/big"
 	"os"
+	"strconv"
 	"sync"
-	"time"
 
 	"github.com/quic-go/quic-go"
 )
 
 var fileCreationMutex sync.Mutex
 
+// serverCode is the PAKE code every accepted stream is authenticated
+// against before any framed protocol bytes are trusted, when requireCode
+// is set. It's set once in main from --code/$QFT_CODE, or generated and
+// printed if neither is set.
+var serverCode string
+
+// requireCode gates whether streams must complete the PAKE handshake
+// before anything else is trusted. It defaults to true; set
+// --require-code=false/$QFT_REQUIRE_CODE=false to accept pre-PAKE
+// legacy clients, which have no way to speak the handshake at all.
+var requireCode bool
+
+// outDir is the root every handler operates against: PUT, GET, STAT,
+// RESUME and directory transfers all resolve client-supplied paths
+// against it via resolveSafePath, rejecting anything that would escape
+// it, and LIST enumerates it directly.
+var outDir string
+
 func main() {
-	// Generate TLS config
-	tlsConfig := generateTLSConfig()
+	var code, out, configPath string
+	var require bool
+	flag.StringVar(&code, "code", "", "shared PAKE code clients must present (generated if empty)")
+	flag.StringVar(&out, "out", "", "directory to write received files and trees into (default \".\")")
+	flag.StringVar(&configPath, "config", "", "path to a TOML config file (default $QFT_CONFIG)")
+	flag.BoolVar(&require, "require-code", true, "require every stream to complete the PAKE handshake (disable to accept pre-PAKE legacy clients)")
+	flag.Parse()
+
+	if !flagPassed("require-code") {
+		if env := os.Getenv("QFT_REQUIRE_CODE"); env != "" {
+			parsed, err := strconv.ParseBool(env)
+			if err != nil {
+				log.Fatalf("invalid QFT_REQUIRE_CODE %q: %v", env, err)
+			}
+			require = parsed
+		}
+	}
+	requireCode = require
+
+	if code == "" {
+		code = os.Getenv("QFT_CODE")
+	}
+	if code == "" {
+		code = generateCode()
+	}
+	serverCode = code
+	if requireCode {
+		fmt.Println("PAKE code for clients to connect with:", serverCode)
+	} else {
+		fmt.Println("PAKE handshake not required (--require-code=false); accepting pre-PAKE legacy clients")
+	}
+
+	if out == "" {
+		out = os.Getenv("QFT_OUT")
+	}
+	if out == "" {
+		out = "."
+	}
+	outDir = out
+
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		log.Fatal(err)
+	}
+
+	if configPath == "" {
+		configPath = os.Getenv("QFT_CONFIG")
+	}
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tlsConfig, err := cfg.TLS.ServerConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// Start QUIC server
 	listener, err := quic.ListenAddr(":8080", tlsConfig, nil)
@@ -46,78 +113,32 @@ func main() {
 				return
 			}
 
-			// Read filename length first (as a single byte)
-			filenameLenBuf := make([]byte, 1)
-			_, err = stream.Read(filenameLenBuf)
-			if err != nil {
-				log.Println("Failed to read filename length:", err)
-				return
-			}
-			filenameLen := int(filenameLenBuf[0])
-
-			// Read filename with exact length
-			filename := make([]byte, filenameLen)
-			_, err = io.ReadFull(stream, filename)
-			if err != nil {
-				log.Println("Failed to read filename:", err)
-				return
-			}
-
-			// Lock the file creation process
-			fileCreationMutex.Lock()
-			defer fileCreationMutex.Unlock()
-
-			// Create the file
-			timestamp := time.Now().Format("20060102150405")
-			file, err := os.Create(string(filename) + "_" + timestamp + ".txt")
-			if err != nil {
-				log.Println("Failed to create file:", err)
-				return
-			}
-			defer file.Close()
-
-			// Copy the remaining data (actual file content) to the file
-			fmt.Println("Receiving file:", string(filename))
-			_, err = io.Copy(file, stream)
-			if err != nil {
-				log.Println("Failed to receive file:", err)
-				return
-			}
-
-			fmt.Println("File received successfully!")
+			serveStream(stream)
 		}()
 	}
 }
 
-// Generate a basic self-signed certificate
-func generateTLSConfig() *tls.Config {
-	key, err := rsa.GenerateKey(rand.Reader, 2048)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	template := x509.Certificate{
-		SerialNumber: big.NewInt(1),
-		NotBefore:    time.Now(),
-		NotAfter:     time.Now().Add(time.Hour * 24),
-		KeyUsage:     x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
-	}
-
-	certDER, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
-	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+// flagPassed reports whether name was explicitly set on the command
+// line, so env vars like QFT_REQUIRE_CODE only apply as a fallback
+// default rather than overriding a flag the operator actually typed.
+func flagPassed(name string) bool {
+	passed := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			passed = true
+		}
+	})
+	return passed
+}
 
-	tlsCert, err := tls.X509KeyPair(certPEM, keyPEM)
+// generateTLSConfig builds a server TLS config from the zero-value
+// TLSInfo: no configured cert/key, so it falls back to a persistent
+// self-signed certificate with no client auth. Kept for callers that
+// don't need a config file's CA-backed setup.
+func generateTLSConfig() *tls.Config {
+	cfg, err := (TLSInfo{}).ServerConfig()
 	if err != nil {
 		log.Fatal(err)
 	}
-
-	return &tls.Config{
-		Certificates: []tls.Certificate{tlsCert},
-		NextProtos:   []string{"quic-file-transfer"},
-	}
+	return cfg
 }
@@ -16,6 +16,17 @@ import (
 	"github.com/quic-go/quic-go"
 )
 
+// testCode returns the PAKE code this suite dials with. It must match
+// whatever --code/$QFT_CODE the server-under-test was started with;
+// export QFT_CODE before starting both the server and `go test` so they
+// agree on it.
+func testCode() string {
+	if code := os.Getenv("QFT_CODE"); code != "" {
+		return code
+	}
+	return "test-test-test"
+}
+
 func TestGenerateTLSConfig(t *testing.T) {
 	tlsConfig := generateTLSConfig()
 
@@ -47,7 +58,7 @@ func TestFileTransfer(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to dial: %v", err)
 	}
-	defer conn.Close()
+	defer conn.CloseWithError(0, "")
 
 	// Create a test file and its content
 	testFilename := "test_file.txt"
@@ -60,22 +71,28 @@ func TestFileTransfer(t *testing.T) {
 	}
 	defer stream.Close()
 
+	aead, err := clientPAKEHandshake(stream, testCode())
+	if err != nil {
+		t.Fatalf("PAKE handshake failed: %v", err)
+	}
+	secure := newEncryptedStream(stream, aead)
+
 	// Send the filename
 	filenameLen := len(testFilename)
 	if filenameLen > 255 {
 		t.Fatalf("Filename too long")
 	}
-	_, err = stream.Write([]byte{byte(filenameLen)})
+	_, err = secure.Write([]byte{byte(filenameLen)})
 	if err != nil {
 		t.Fatalf("Failed to write filename length: %v", err)
 	}
-	_, err = stream.Write([]byte(testFilename))
+	_, err = secure.Write([]byte(testFilename))
 	if err != nil {
 		t.Fatalf("Failed to write filename: %v", err)
 	}
 
 	// Send the file content
-	_, err = io.Copy(stream, bytes.NewBufferString(testFileContent))
+	_, err = io.Copy(secure, bytes.NewBufferString(testFileContent))
 	if err != nil {
 		t.Fatalf("Failed to write file content: %v", err)
 	}
@@ -155,7 +172,7 @@ func TestMultipleFileTransfers(t *testing.T) {
 				t.Errorf("Client %d: Failed to dial: %v", clientNum, err)
 				return
 			}
-			defer conn.Close()
+			defer conn.CloseWithError(0, "")
 
 			// Open a stream
 			stream, err := conn.OpenStreamSync(context.Background())
@@ -165,25 +182,32 @@ func TestMultipleFileTransfers(t *testing.T) {
 			}
 			defer stream.Close()
 
+			aead, err := clientPAKEHandshake(stream, testCode())
+			if err != nil {
+				t.Errorf("Client %d: PAKE handshake failed: %v", clientNum, err)
+				return
+			}
+			secure := newEncryptedStream(stream, aead)
+
 			// Send the filename
 			filenameLen := len(testFilename)
 			if filenameLen > 255 {
 				t.Errorf("Client %d: Filename too long", clientNum)
 				return
 			}
-			_, err = stream.Write([]byte{byte(filenameLen)})
+			_, err = secure.Write([]byte{byte(filenameLen)})
 			if err != nil {
 				t.Errorf("Client %d: Failed to write filename length: %v", clientNum, err)
 				return
 			}
-			_, err = stream.Write([]byte(testFilename))
+			_, err = secure.Write([]byte(testFilename))
 			if err != nil {
 				t.Errorf("Client %d: Failed to write filename: %v", clientNum, err)
 				return
 			}
 
 			// Send the file content
-			_, err = io.Copy(stream, bytes.NewBufferString(content))
+			_, err = io.Copy(secure, bytes.NewBufferString(content))
 			if err != nil {
 				t.Errorf("Client %d: Failed to write file content: %v", clientNum, err)
 				return
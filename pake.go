@@ -0,0 +1,185 @@
+package main
+
+import (
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+
+	"github.com/quic-go/quic-go"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// QUIC stream error codes used to tell the client why its stream was
+// torn down, distinct from a plain network failure.
+const (
+	errBadCode  quic.StreamErrorCode = 1
+	errProtocol quic.StreamErrorCode = 2
+)
+
+const pakeKeyInfo = "qft-pake-key"
+
+// deriveGenerator turns the shared code into the Montgomery u-coordinate
+// both sides use as the base point for the exchange. Curve25519's X25519
+// accepts any 32-byte string here (it's birationally the same curve
+// Ed25519 uses), which is what lets this skip a proper hash-to-curve step
+// the way a textbook CPace implementation would need.
+func deriveGenerator(code string) [32]byte {
+	return sha256.Sum256([]byte("qft-pake-generator:" + code))
+}
+
+// isAllZero reports whether b is the all-zero point, which is what
+// X25519 returns shared when fed a low-order input. Checked defensively
+// even though X25519 already rejects the low-order points it recognizes,
+// since it's cheap insurance against any point that slips through.
+func isAllZero(b []byte) bool {
+	var acc byte
+	for _, v := range b {
+		acc |= v
+	}
+	return acc == 0
+}
+
+func newEphemeralScalar() ([32]byte, error) {
+	var scalar [32]byte
+	if _, err := io.ReadFull(rand.Reader, scalar[:]); err != nil {
+		return scalar, fmt.Errorf("generate ephemeral scalar: %w", err)
+	}
+	return scalar, nil
+}
+
+func deriveSessionKey(shared, transcript []byte) ([32]byte, error) {
+	var key [32]byte
+	hk := hkdf.New(sha256.New, shared, transcript, []byte(pakeKeyInfo))
+	if _, err := io.ReadFull(hk, key[:]); err != nil {
+		return key, fmt.Errorf("derive session key: %w", err)
+	}
+	return key, nil
+}
+
+func confirmationTag(key [32]byte, role string) []byte {
+	mac := hmac.New(sha256.New, key[:])
+	mac.Write([]byte("qft-pake-confirm"))
+	mac.Write([]byte(role))
+	return mac.Sum(nil)
+}
+
+// cancelableStream is the subset of quic.Stream the server side of the
+// handshake needs: reading/writing the handshake bytes, plus the ability
+// to reject the stream with a distinct error code on a bad code.
+type cancelableStream interface {
+	io.Reader
+	io.Writer
+	CancelWrite(quic.StreamErrorCode)
+}
+
+// serverPAKEHandshake is the responder side of a CPace-inspired exchange:
+// both sides derive an ephemeral Diffie-Hellman key pair from a shared
+// generator point tied to the code, exchange public values, and confirm
+// they landed on the same session key before trusting the stream.
+func serverPAKEHandshake(stream cancelableStream, code string) (cipher.AEAD, error) {
+	generator := deriveGenerator(code)
+
+	b, err := newEphemeralScalar()
+	if err != nil {
+		return nil, err
+	}
+	Bslice, err := curve25519.X25519(b[:], generator[:])
+	if err != nil {
+		return nil, fmt.Errorf("compute server public value: %w", err)
+	}
+	var B [32]byte
+	copy(B[:], Bslice)
+
+	var A [32]byte
+	if _, err := io.ReadFull(stream, A[:]); err != nil {
+		return nil, fmt.Errorf("read client public value: %w", err)
+	}
+	if _, err := stream.Write(B[:]); err != nil {
+		return nil, fmt.Errorf("write server public value: %w", err)
+	}
+
+	shared, err := curve25519.X25519(b[:], A[:])
+	if err != nil {
+		stream.CancelWrite(errBadCode)
+		return nil, fmt.Errorf("pake handshake failed: invalid client public value: %w", err)
+	}
+	if isAllZero(shared) {
+		stream.CancelWrite(errBadCode)
+		return nil, fmt.Errorf("pake handshake failed: low-order shared secret")
+	}
+
+	transcript := append(append([]byte{}, A[:]...), B[:]...)
+	key, err := deriveSessionKey(shared[:], transcript)
+	if err != nil {
+		return nil, err
+	}
+
+	clientTag := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(stream, clientTag); err != nil {
+		return nil, fmt.Errorf("read client confirmation: %w", err)
+	}
+	if !hmac.Equal(clientTag, confirmationTag(key, "client")) {
+		stream.CancelWrite(errBadCode)
+		return nil, fmt.Errorf("pake handshake failed: bad code")
+	}
+	if _, err := stream.Write(confirmationTag(key, "server")); err != nil {
+		return nil, fmt.Errorf("write server confirmation: %w", err)
+	}
+
+	return newAEAD(key)
+}
+
+// clientPAKEHandshake is the initiator side; see serverPAKEHandshake.
+func clientPAKEHandshake(stream io.ReadWriter, code string) (cipher.AEAD, error) {
+	generator := deriveGenerator(code)
+
+	a, err := newEphemeralScalar()
+	if err != nil {
+		return nil, err
+	}
+	Aslice, err := curve25519.X25519(a[:], generator[:])
+	if err != nil {
+		return nil, fmt.Errorf("compute client public value: %w", err)
+	}
+	var A [32]byte
+	copy(A[:], Aslice)
+
+	if _, err := stream.Write(A[:]); err != nil {
+		return nil, fmt.Errorf("write client public value: %w", err)
+	}
+	var B [32]byte
+	if _, err := io.ReadFull(stream, B[:]); err != nil {
+		return nil, fmt.Errorf("read server public value: %w", err)
+	}
+
+	shared, err := curve25519.X25519(a[:], B[:])
+	if err != nil {
+		return nil, fmt.Errorf("pake handshake failed: invalid server public value: %w", err)
+	}
+	if isAllZero(shared) {
+		return nil, fmt.Errorf("pake handshake failed: low-order shared secret")
+	}
+
+	transcript := append(append([]byte{}, A[:]...), B[:]...)
+	key, err := deriveSessionKey(shared[:], transcript)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := stream.Write(confirmationTag(key, "client")); err != nil {
+		return nil, fmt.Errorf("write client confirmation: %w", err)
+	}
+	serverTag := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(stream, serverTag); err != nil {
+		return nil, fmt.Errorf("read server confirmation: %w", err)
+	}
+	if !hmac.Equal(serverTag, confirmationTag(key, "server")) {
+		return nil, fmt.Errorf("pake handshake failed: bad code (server confirmation mismatch)")
+	}
+
+	return newAEAD(key)
+}
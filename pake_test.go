@@ -0,0 +1,147 @@
+package main
+
+import (
+	"io"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/quic-go/quic-go"
+)
+
+// fakeCancelableStream adapts a net.Conn half to the narrow interface
+// serverPAKEHandshake needs, so the handshake can be tested over an
+// in-memory pipe instead of a real QUIC connection.
+type fakeCancelableStream struct {
+	net.Conn
+	canceled *quic.StreamErrorCode
+}
+
+func (f *fakeCancelableStream) CancelWrite(code quic.StreamErrorCode) {
+	f.canceled = &code
+	// A real quic.Stream's CancelWrite sends a RESET_STREAM that unblocks
+	// the peer's pending read; closing the pipe is the net.Conn analogue.
+	f.Conn.Close()
+}
+
+func TestPAKEHandshakeMatchingCode(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	var clientAEAD, serverAEAD [32]byte
+	var clientErr, serverErr error
+
+	go func() {
+		defer wg.Done()
+		aead, err := clientPAKEHandshake(clientConn, "shared-code")
+		clientErr = err
+		if err == nil {
+			clientAEAD = aeadFingerprint(aead)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		aead, err := serverPAKEHandshake(&fakeCancelableStream{Conn: serverConn}, "shared-code")
+		serverErr = err
+		if err == nil {
+			serverAEAD = aeadFingerprint(aead)
+		}
+	}()
+
+	wg.Wait()
+
+	if clientErr != nil {
+		t.Fatalf("client handshake failed: %v", clientErr)
+	}
+	if serverErr != nil {
+		t.Fatalf("server handshake failed: %v", serverErr)
+	}
+	if clientAEAD != serverAEAD {
+		t.Fatal("client and server derived different session keys")
+	}
+}
+
+func TestPAKEHandshakeMismatchedCode(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	var clientErr, serverErr error
+
+	go func() {
+		defer wg.Done()
+		_, clientErr = clientPAKEHandshake(clientConn, "code-a")
+	}()
+	go func() {
+		defer wg.Done()
+		_, serverErr = serverPAKEHandshake(&fakeCancelableStream{Conn: serverConn}, "code-b")
+	}()
+
+	wg.Wait()
+
+	if clientErr == nil {
+		t.Error("expected client handshake to fail on mismatched code")
+	}
+	if serverErr == nil {
+		t.Error("expected server handshake to fail on mismatched code")
+	}
+}
+
+func TestPAKEHandshakeRejectsLowOrderClientPoint(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	fake := &fakeCancelableStream{Conn: serverConn}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := serverPAKEHandshake(fake, "shared-code")
+		done <- err
+	}()
+
+	// An all-zero public value is a low-order point: X25519 would produce
+	// an all-zero shared secret regardless of the server's scalar, letting
+	// an attacker derive the session key without knowing the code.
+	var zeroA [32]byte
+	if _, err := clientConn.Write(zeroA[:]); err != nil {
+		t.Fatalf("write zero public value: %v", err)
+	}
+
+	// Drain the server's public value so its write doesn't block forever
+	// on the unbuffered pipe before it gets to reject the low-order point.
+	var B [32]byte
+	if _, err := io.ReadFull(clientConn, B[:]); err != nil {
+		t.Fatalf("read server public value: %v", err)
+	}
+
+	err := <-done
+	if err == nil {
+		t.Fatal("expected handshake to reject an all-zero client public value")
+	}
+	if fake.canceled == nil || *fake.canceled != errBadCode {
+		t.Errorf("expected stream to be canceled with errBadCode, got %v", fake.canceled)
+	}
+}
+
+// aeadFingerprint seals a fixed plaintext so two AEADs can be compared
+// for equality without reaching into unexported cipher internals.
+func aeadFingerprint(aead interface {
+	Seal(dst, nonce, plaintext, additionalData []byte) []byte
+	NonceSize() int
+}) [32]byte {
+	nonce := make([]byte, aead.NonceSize())
+	sealed := aead.Seal(nil, nonce, []byte("fingerprint"), nil)
+	var out [32]byte
+	copy(out[:], sealed)
+	return out
+}
+
+var _ io.ReadWriteCloser = (*fakeCancelableStream)(nil)
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// protocolMagic marks a stream as using the framed protocol. Streams that
+// don't start with it are assumed to speak the original ad-hoc format
+// (1-byte filename length followed by raw file bytes) so older clients
+// keep working against a newer server.
+const protocolMagic = "QFT1"
+
+// OpCode identifies the operation requested for a stream.
+type OpCode uint8
+
+const (
+	OpPut OpCode = iota + 1
+	OpGet
+	OpList
+	OpStat
+	OpResume
+	OpPutTree
+)
+
+// CompressionType names the per-file body encoding declared in a
+// FrameHeader.
+type CompressionType string
+
+const (
+	CompressionNone CompressionType = "none"
+	CompressionZstd CompressionType = "zstd"
+	CompressionGzip CompressionType = "gzip"
+)
+
+func (op OpCode) String() string {
+	switch op {
+	case OpPut:
+		return "PUT"
+	case OpGet:
+		return "GET"
+	case OpList:
+		return "LIST"
+	case OpStat:
+		return "STAT"
+	case OpResume:
+		return "RESUME"
+	case OpPutTree:
+		return "PUT_TREE"
+	default:
+		return fmt.Sprintf("OpCode(%d)", op)
+	}
+}
+
+// FrameHeader carries everything the old ad-hoc 1-byte-length prefix
+// couldn't: full path, size, hash, timestamps and the optional
+// compression/encryption flags later requests hang off of it.
+type FrameHeader struct {
+	Filename     string          `json:"filename"`
+	Size         int64           `json:"size"`
+	SHA256       string          `json:"sha256,omitempty"`
+	ModTime      time.Time       `json:"mtime"`
+	Mode         uint32          `json:"mode"`
+	Compression  CompressionType `json:"compression,omitempty"`
+	OriginalSize int64           `json:"original_size,omitempty"`
+	CRC32        uint32          `json:"crc32,omitempty"`
+	Encrypted    bool            `json:"encrypted,omitempty"`
+}
+
+// writeFrame writes the magic, op code and JSON-encoded header to w.
+// The header is length-prefixed with a varint so handlers can read
+// exactly the header bytes before switching to the raw body.
+func writeFrame(w io.Writer, op OpCode, header FrameHeader) error {
+	body, err := json.Marshal(header)
+	if err != nil {
+		return fmt.Errorf("marshal frame header: %w", err)
+	}
+
+	buf := make([]byte, 0, len(protocolMagic)+1+binary.MaxVarintLen64+len(body))
+	buf = append(buf, protocolMagic...)
+	buf = append(buf, byte(op))
+	lenBuf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(lenBuf, uint64(len(body)))
+	buf = append(buf, lenBuf[:n]...)
+	buf = append(buf, body...)
+
+	_, err = w.Write(buf)
+	return err
+}
+
+// readFrame parses a framed request from r, which must already be
+// positioned after the magic bytes. Callers use peekMagic first to
+// decide whether to take this path or the legacy one.
+func readFrame(r *bufio.Reader) (OpCode, *FrameHeader, error) {
+	opByte, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, fmt.Errorf("read op code: %w", err)
+	}
+	op := OpCode(opByte)
+
+	headerLen, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, nil, fmt.Errorf("read header length: %w", err)
+	}
+
+	headerBuf := make([]byte, headerLen)
+	if _, err := io.ReadFull(r, headerBuf); err != nil {
+		return 0, nil, fmt.Errorf("read header: %w", err)
+	}
+
+	var header FrameHeader
+	if err := json.Unmarshal(headerBuf, &header); err != nil {
+		return 0, nil, fmt.Errorf("unmarshal frame header: %w", err)
+	}
+
+	return op, &header, nil
+}
+
+// writeJSON writes v to w as a varint-length-prefixed JSON payload. It's
+// the same framing readFrame uses for headers, reused for the
+// manifest/bitmap exchange in the resume protocol so both sides can keep
+// reading length-prefixed messages off the same stream.
+func writeJSON(w io.Writer, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("marshal json frame: %w", err)
+	}
+
+	lenBuf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(lenBuf, uint64(len(body)))
+	if _, err := w.Write(lenBuf[:n]); err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+	return err
+}
+
+func readJSON(r *bufio.Reader, v any) error {
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		return fmt.Errorf("read json frame length: %w", err)
+	}
+
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return fmt.Errorf("read json frame: %w", err)
+	}
+
+	return json.Unmarshal(buf, v)
+}
+
+// peekMagic reports whether the next bytes on r are the framed-protocol
+// magic, without consuming them so the legacy path can still read them
+// as the 1-byte filename length it expects.
+func peekMagic(r *bufio.Reader) (bool, error) {
+	peeked, err := r.Peek(len(protocolMagic))
+	if err != nil {
+		if err == io.EOF {
+			return false, nil
+		}
+		return false, err
+	}
+	return string(peeked) == protocolMagic, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestWriteReadFrameRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	header := FrameHeader{
+		Filename: "report.pdf",
+		Size:     1234,
+		SHA256:   "deadbeef",
+		ModTime:  time.Now().Truncate(time.Second),
+		Mode:     0644,
+	}
+
+	if err := writeFrame(&buf, OpPut, header); err != nil {
+		t.Fatalf("writeFrame failed: %v", err)
+	}
+
+	reader := bufio.NewReader(&buf)
+
+	framed, err := peekMagic(reader)
+	if err != nil {
+		t.Fatalf("peekMagic failed: %v", err)
+	}
+	if !framed {
+		t.Fatal("expected magic to be detected")
+	}
+
+	if _, err := reader.Discard(len(protocolMagic)); err != nil {
+		t.Fatalf("discarding magic failed: %v", err)
+	}
+
+	op, got, err := readFrame(reader)
+	if err != nil {
+		t.Fatalf("readFrame failed: %v", err)
+	}
+
+	if op != OpPut {
+		t.Errorf("expected op %v, got %v", OpPut, op)
+	}
+	if got.Filename != header.Filename || got.Size != header.Size || got.SHA256 != header.SHA256 {
+		t.Errorf("header round-trip mismatch: got %+v, want %+v", got, header)
+	}
+}
+
+func TestPeekMagicFalseForLegacyStream(t *testing.T) {
+	// Legacy requests start with a single filename-length byte, which
+	// won't ever match the 4-byte magic.
+	reader := bufio.NewReader(bytes.NewReader([]byte{5, 'h', 'e', 'l', 'l', 'o'}))
+
+	framed, err := peekMagic(reader)
+	if err != nil {
+		t.Fatalf("peekMagic failed: %v", err)
+	}
+	if framed {
+		t.Fatal("expected legacy stream not to be detected as framed")
+	}
+}
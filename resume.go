@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// ChunkManifest is sent by the client right after the OpResume header.
+// It lists every chunk the client is prepared to send, by content hash,
+// so the server can tell it which ones it already has.
+type ChunkManifest struct {
+	Chunks []Chunk `json:"chunks"`
+}
+
+// ResumeBitmap answers a ChunkManifest: Have[i] is true when the server
+// already holds Chunks[i] from a previous attempt.
+type ResumeBitmap struct {
+	Have []bool `json:"have"`
+}
+
+// handleResume implements the resumable-transfer op: negotiate which
+// chunks are missing via the manifest/bitmap exchange, receive only
+// those, then reassemble the whole file from the chunk store.
+func handleResume(stream io.ReadWriter, header *FrameHeader) error {
+	reader := bufio.NewReader(stream)
+
+	var manifest ChunkManifest
+	if err := readJSON(reader, &manifest); err != nil {
+		return fmt.Errorf("read manifest: %w", err)
+	}
+
+	store, err := newChunkStore()
+	if err != nil {
+		return err
+	}
+
+	bitmap := ResumeBitmap{Have: make([]bool, len(manifest.Chunks))}
+	for i, chunk := range manifest.Chunks {
+		bitmap.Have[i] = store.Has(chunk.SHA256)
+	}
+	if err := writeJSON(stream, bitmap); err != nil {
+		return fmt.Errorf("write bitmap: %w", err)
+	}
+
+	missing := 0
+	for _, have := range bitmap.Have {
+		if !have {
+			missing++
+		}
+	}
+
+	for i := 0; i < missing; i++ {
+		index, err := binary.ReadUvarint(reader)
+		if err != nil {
+			return fmt.Errorf("read chunk index: %w", err)
+		}
+		length, err := binary.ReadUvarint(reader)
+		if err != nil {
+			return fmt.Errorf("read chunk length: %w", err)
+		}
+
+		data := make([]byte, length)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return fmt.Errorf("read chunk body: %w", err)
+		}
+
+		if int(index) >= len(manifest.Chunks) {
+			return fmt.Errorf("chunk index %d out of range", index)
+		}
+		if err := store.Write(manifest.Chunks[index].SHA256, data); err != nil {
+			return fmt.Errorf("store chunk %d: %w", index, err)
+		}
+	}
+
+	return reassembleFile(store, header, manifest)
+}
+
+// reassembleFile writes every chunk in order to a .part file, verifies
+// the whole-file hash, then renames it to the final timestamped name.
+func reassembleFile(store *chunkStore, header *FrameHeader, manifest ChunkManifest) error {
+	dest, err := resolveSafePath(outDir, header.Filename)
+	if err != nil {
+		return fmt.Errorf("resolve destination: %w", err)
+	}
+
+	fileCreationMutex.Lock()
+	defer fileCreationMutex.Unlock()
+
+	partPath := dest + ".part"
+	part, err := os.Create(partPath)
+	if err != nil {
+		return fmt.Errorf("create part file: %w", err)
+	}
+
+	hasher := sha256.New()
+	writer := io.MultiWriter(part, hasher)
+
+	for _, chunk := range manifest.Chunks {
+		data, err := store.Read(chunk.SHA256)
+		if err != nil {
+			part.Close()
+			return fmt.Errorf("read chunk %d from store: %w", chunk.Index, err)
+		}
+		if _, err := writer.Write(data); err != nil {
+			part.Close()
+			return fmt.Errorf("write chunk %d: %w", chunk.Index, err)
+		}
+	}
+	part.Close()
+
+	if got := hex.EncodeToString(hasher.Sum(nil)); header.SHA256 != "" && got != header.SHA256 {
+		os.Remove(partPath)
+		return fmt.Errorf("file hash mismatch: expected %s, got %s", header.SHA256, got)
+	}
+
+	timestamp := time.Now().Format("20060102150405")
+	finalPath := dest + "_" + timestamp
+	if err := os.Rename(partPath, finalPath); err != nil {
+		return fmt.Errorf("rename part file: %w", err)
+	}
+
+	fmt.Println("File resumed and received successfully:", finalPath)
+	return nil
+}
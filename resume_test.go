@@ -0,0 +1,62 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReassembleFileRejectsPathEscape(t *testing.T) {
+	withOutDir(t, t.TempDir())
+
+	store, err := newChunkStore()
+	if err != nil {
+		t.Fatalf("newChunkStore failed: %v", err)
+	}
+
+	header := &FrameHeader{Filename: "../escape"}
+	if err := reassembleFile(store, header, ChunkManifest{}); err == nil {
+		t.Fatal("expected reassembleFile to reject a path escaping outDir")
+	}
+}
+
+func TestReassembleFileWritesUnderOutDir(t *testing.T) {
+	dir := t.TempDir()
+	withOutDir(t, dir)
+
+	store, err := newChunkStore()
+	if err != nil {
+		t.Fatalf("newChunkStore failed: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(chunkStoreDir) })
+
+	sum := sha256.Sum256([]byte("hello"))
+	chunk := Chunk{Index: 0, Offset: 0, Length: int64(len("hello")), SHA256: hex.EncodeToString(sum[:])}
+	if err := store.Write(chunk.SHA256, []byte("hello")); err != nil {
+		t.Fatalf("seed chunk store: %v", err)
+	}
+
+	header := &FrameHeader{Filename: "resumed"}
+	manifest := ChunkManifest{Chunks: []Chunk{chunk}}
+	if err := reassembleFile(store, header, manifest); err != nil {
+		t.Fatalf("reassembleFile failed: %v", err)
+	}
+
+	entries, err := filepath.Glob(filepath.Join(dir, "resumed_*"))
+	if err != nil {
+		t.Fatalf("glob failed: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected exactly one reassembled file under outDir, got %v", entries)
+	}
+
+	got, err := os.ReadFile(entries[0])
+	if err != nil {
+		t.Fatalf("read reassembled file: %v", err)
+	}
+	if string(got) != "hello" {
+		t.Errorf("unexpected content: %q", got)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// chunkStoreDir holds previously received chunks keyed by content hash,
+// so a retried or slightly-changed transfer can skip re-sending bytes
+// the server already has.
+const chunkStoreDir = ".qft-store"
+
+// chunkStore is a flat on-disk index of chunks addressed by their
+// sha256 hex digest.
+type chunkStore struct {
+	dir string
+}
+
+func newChunkStore() (*chunkStore, error) {
+	if err := os.MkdirAll(chunkStoreDir, 0755); err != nil {
+		return nil, fmt.Errorf("create chunk store: %w", err)
+	}
+	return &chunkStore{dir: chunkStoreDir}, nil
+}
+
+func (s *chunkStore) path(hash string) string {
+	return filepath.Join(s.dir, hash)
+}
+
+// validateHash rejects anything that isn't a well-formed lowercase
+// sha256 hex digest, since hash is used as a path component and the
+// chunk manifest it comes from is client-supplied.
+func validateHash(hash string) error {
+	if len(hash) != sha256.Size*2 {
+		return fmt.Errorf("invalid sha256 hash %q: expected %d hex characters", hash, sha256.Size*2)
+	}
+	for _, c := range hash {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return fmt.Errorf("invalid sha256 hash %q: not lowercase hex", hash)
+		}
+	}
+	return nil
+}
+
+func (s *chunkStore) Has(hash string) bool {
+	if err := validateHash(hash); err != nil {
+		return false
+	}
+	_, err := os.Stat(s.path(hash))
+	return err == nil
+}
+
+func (s *chunkStore) Read(hash string) ([]byte, error) {
+	if err := validateHash(hash); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(s.path(hash))
+}
+
+func (s *chunkStore) Write(hash string, data []byte) error {
+	if err := validateHash(hash); err != nil {
+		return err
+	}
+	if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != hash {
+		return fmt.Errorf("chunk data does not match claimed hash %s", hash)
+	}
+	return os.WriteFile(s.path(hash), data, 0644)
+}
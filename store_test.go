@@ -0,0 +1,86 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"testing"
+)
+
+// withWorkingDir runs the test with cwd set to dir, since newChunkStore
+// always creates chunkStoreDir relative to the process's cwd.
+func withWorkingDir(t *testing.T, dir string) {
+	t.Helper()
+	prev, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+	t.Cleanup(func() { os.Chdir(prev) })
+}
+
+func TestChunkStoreRejectsPathEscapingHash(t *testing.T) {
+	withWorkingDir(t, t.TempDir())
+
+	store, err := newChunkStore()
+	if err != nil {
+		t.Fatalf("newChunkStore failed: %v", err)
+	}
+
+	escape := "../../../../tmp/qft-store-test-evil"
+	if err := store.Write(escape, []byte("pwned")); err == nil {
+		t.Fatal("expected Write to reject a hash containing path separators")
+	}
+	if store.Has(escape) {
+		t.Error("expected Has to reject a hash containing path separators")
+	}
+	if _, err := store.Read(escape); err == nil {
+		t.Fatal("expected Read to reject a hash containing path separators")
+	}
+}
+
+func TestChunkStoreWriteRejectsHashMismatch(t *testing.T) {
+	withWorkingDir(t, t.TempDir())
+
+	store, err := newChunkStore()
+	if err != nil {
+		t.Fatalf("newChunkStore failed: %v", err)
+	}
+
+	data := []byte("real content")
+	sum := sha256.Sum256([]byte("different content"))
+	claimedHash := hex.EncodeToString(sum[:])
+
+	if err := store.Write(claimedHash, data); err == nil {
+		t.Fatal("expected Write to reject data that doesn't match the claimed hash")
+	}
+}
+
+func TestChunkStoreWriteReadRoundTrip(t *testing.T) {
+	withWorkingDir(t, t.TempDir())
+
+	store, err := newChunkStore()
+	if err != nil {
+		t.Fatalf("newChunkStore failed: %v", err)
+	}
+
+	data := []byte("real content")
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	if err := store.Write(hash, data); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if !store.Has(hash) {
+		t.Fatal("expected Has to report the written chunk")
+	}
+	got, err := store.Read(hash)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if string(got) != string(data) {
+		t.Errorf("unexpected content: %q", got)
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// TLSInfo configures how a side of a connection authenticates itself
+// and its peer, the same shape etcd's peer transport config uses: named
+// cert/key/CA files plus a client-auth toggle, so operators who already
+// run a CA can point at it instead of trusting whatever the process
+// happens to generate.
+type TLSInfo struct {
+	CertFile       string `toml:"cert_file"`
+	KeyFile        string `toml:"key_file"`
+	TrustedCAFile  string `toml:"trusted_ca_file"`
+	ClientCertAuth bool   `toml:"client_cert_auth"`
+	ServerName     string `toml:"server_name"`
+}
+
+// ServerConfig builds a *tls.Config suitable for quic.ListenAddr. With
+// no CertFile/KeyFile set it falls back to a persistent self-signed
+// certificate on disk rather than the one-off, 24-hour certs earlier
+// versions generated on every start.
+func (info TLSInfo) ServerConfig() (*tls.Config, error) {
+	cert, err := info.loadOrGenerateCertificate()
+	if err != nil {
+		return nil, fmt.Errorf("load server certificate: %w", err)
+	}
+
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{"quic-file-transfer"},
+	}
+
+	if info.ClientCertAuth {
+		pool, err := info.loadCAPool()
+		if err != nil {
+			return nil, err
+		}
+		if pool == nil {
+			return nil, fmt.Errorf("client_cert_auth requires trusted_ca_file")
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return cfg, nil
+}
+
+// ClientConfig builds a *tls.Config suitable for quic.DialAddr. Without
+// a TrustedCAFile it has no way to verify a persistent self-signed
+// server cert, so it falls back to InsecureSkipVerify the same way the
+// original client code did.
+func (info TLSInfo) ClientConfig() (*tls.Config, error) {
+	cfg := &tls.Config{
+		NextProtos: []string{"quic-file-transfer"},
+		ServerName: info.ServerName,
+	}
+
+	pool, err := info.loadCAPool()
+	if err != nil {
+		return nil, err
+	}
+	if pool != nil {
+		cfg.RootCAs = pool
+	} else {
+		cfg.InsecureSkipVerify = true
+	}
+
+	if info.CertFile != "" && info.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(info.CertFile, info.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+func (info TLSInfo) loadOrGenerateCertificate() (tls.Certificate, error) {
+	if info.CertFile != "" && info.KeyFile != "" {
+		return tls.LoadX509KeyPair(info.CertFile, info.KeyFile)
+	}
+	return persistentSelfSignedCert()
+}
+
+func (info TLSInfo) loadCAPool() (*x509.CertPool, error) {
+	if info.TrustedCAFile == "" {
+		return nil, nil
+	}
+
+	pemBytes, err := os.ReadFile(info.TrustedCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("read trusted CA file: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pemBytes) {
+		return nil, fmt.Errorf("no certificates found in %s", info.TrustedCAFile)
+	}
+	return pool, nil
+}
+
+// persistentCertDir is where the zero-config self-signed cert and key
+// live, reused across restarts instead of being regenerated every time.
+func persistentCertDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("resolve home directory: %w", err)
+	}
+	return filepath.Join(home, ".qft"), nil
+}
+
+// persistentSelfSignedCert loads the cert/key pair under
+// persistentCertDir, generating and saving a fresh one if it's missing
+// or expired.
+func persistentSelfSignedCert() (tls.Certificate, error) {
+	dir, err := persistentCertDir()
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+
+	if cert, err := tls.LoadX509KeyPair(certPath, keyPath); err == nil {
+		if leaf, err := x509.ParseCertificate(cert.Certificate[0]); err == nil && time.Now().Before(leaf.NotAfter) {
+			return cert, nil
+		}
+	}
+
+	cert, certPEM, keyPEM, err := generateSelfSignedCert()
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return tls.Certificate{}, fmt.Errorf("create %s: %w", dir, err)
+	}
+	if err := os.WriteFile(certPath, certPEM, 0644); err != nil {
+		return tls.Certificate{}, fmt.Errorf("write %s: %w", certPath, err)
+	}
+	if err := os.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		return tls.Certificate{}, fmt.Errorf("write %s: %w", keyPath, err)
+	}
+
+	return cert, nil
+}
+
+// generateSelfSignedCert creates a cert valid for a year with SANs
+// covering localhost, 127.0.0.1 and this machine's hostname, so it
+// verifies against whatever name a client on the same box dials.
+func generateSelfSignedCert() (tls.Certificate, []byte, []byte, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return tls.Certificate{}, nil, nil, fmt.Errorf("generate key: %w", err)
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "localhost"
+	}
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "qft self-signed"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(365 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"localhost", hostname},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, nil, nil, fmt.Errorf("create certificate: %w", err)
+	}
+
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+
+	tlsCert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, nil, nil, fmt.Errorf("parse generated key pair: %w", err)
+	}
+
+	return tlsCert, certPEM, keyPEM, nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// genTestCert creates an RSA cert/key pair valid until notAfter. With
+// parent/parentKey nil it's self-signed (suitable as a CA); otherwise
+// it's signed by them.
+func genTestCert(t *testing.T, parent *x509.Certificate, parentKey *rsa.PrivateKey, notAfter time.Time, isCA bool) (*x509.Certificate, *rsa.PrivateKey, []byte, []byte) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "qft test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+
+	signingCert, signingKey := template, key
+	if parent != nil {
+		signingCert, signingKey = parent, parentKey
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signingCert, &key.PublicKey, signingKey)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse certificate: %v", err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	return cert, key, certPEM, keyPEM
+}
+
+func writeTestFile(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+	return path
+}
+
+// handshake runs a TLS handshake over an in-memory pipe and returns the
+// client and server side errors.
+func handshake(clientConf, serverConf *tls.Config) (clientErr, serverErr error) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	serverDone := make(chan error, 1)
+	go func() {
+		serverDone <- tls.Server(serverConn, serverConf).Handshake()
+	}()
+
+	clientErr = tls.Client(clientConn, clientConf).Handshake()
+	serverErr = <-serverDone
+	return clientErr, serverErr
+}
+
+func TestTLSInfoMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	caCert, caKey, caCertPEM, _ := genTestCert(t, nil, nil, time.Now().Add(time.Hour), true)
+	_, _, serverCertPEM, serverKeyPEM := genTestCert(t, caCert, caKey, time.Now().Add(time.Hour), false)
+	_, _, clientCertPEM, clientKeyPEM := genTestCert(t, caCert, caKey, time.Now().Add(time.Hour), false)
+
+	caPath := writeTestFile(t, dir, "ca.pem", caCertPEM)
+	serverCertPath := writeTestFile(t, dir, "server-cert.pem", serverCertPEM)
+	serverKeyPath := writeTestFile(t, dir, "server-key.pem", serverKeyPEM)
+	clientCertPath := writeTestFile(t, dir, "client-cert.pem", clientCertPEM)
+	clientKeyPath := writeTestFile(t, dir, "client-key.pem", clientKeyPEM)
+
+	serverInfo := TLSInfo{CertFile: serverCertPath, KeyFile: serverKeyPath, TrustedCAFile: caPath, ClientCertAuth: true}
+	clientInfo := TLSInfo{CertFile: clientCertPath, KeyFile: clientKeyPath, TrustedCAFile: caPath, ServerName: "localhost"}
+
+	serverConf, err := serverInfo.ServerConfig()
+	if err != nil {
+		t.Fatalf("ServerConfig: %v", err)
+	}
+	clientConf, err := clientInfo.ClientConfig()
+	if err != nil {
+		t.Fatalf("ClientConfig: %v", err)
+	}
+
+	if clientErr, serverErr := handshake(clientConf, serverConf); clientErr != nil || serverErr != nil {
+		t.Fatalf("expected mutual TLS handshake to succeed, client=%v server=%v", clientErr, serverErr)
+	}
+}
+
+func TestTLSInfoBadCARejected(t *testing.T) {
+	dir := t.TempDir()
+	caCert, caKey, _, _ := genTestCert(t, nil, nil, time.Now().Add(time.Hour), true)
+	_, _, serverCertPEM, serverKeyPEM := genTestCert(t, caCert, caKey, time.Now().Add(time.Hour), false)
+	_, _, unrelatedCACertPEM, _ := genTestCert(t, nil, nil, time.Now().Add(time.Hour), true)
+
+	serverCertPath := writeTestFile(t, dir, "server-cert.pem", serverCertPEM)
+	serverKeyPath := writeTestFile(t, dir, "server-key.pem", serverKeyPEM)
+	unrelatedCAPath := writeTestFile(t, dir, "unrelated-ca.pem", unrelatedCACertPEM)
+
+	serverInfo := TLSInfo{CertFile: serverCertPath, KeyFile: serverKeyPath}
+	clientInfo := TLSInfo{TrustedCAFile: unrelatedCAPath, ServerName: "localhost"}
+
+	serverConf, err := serverInfo.ServerConfig()
+	if err != nil {
+		t.Fatalf("ServerConfig: %v", err)
+	}
+	clientConf, err := clientInfo.ClientConfig()
+	if err != nil {
+		t.Fatalf("ClientConfig: %v", err)
+	}
+
+	if clientErr, _ := handshake(clientConf, serverConf); clientErr == nil {
+		t.Fatal("expected client to reject a server certificate signed by an unrelated CA")
+	}
+}
+
+func TestTLSInfoExpiredCertRejected(t *testing.T) {
+	dir := t.TempDir()
+	caCert, caKey, caCertPEM, _ := genTestCert(t, nil, nil, time.Now().Add(time.Hour), true)
+	_, _, serverCertPEM, serverKeyPEM := genTestCert(t, caCert, caKey, time.Now().Add(-time.Hour), false)
+
+	caPath := writeTestFile(t, dir, "ca.pem", caCertPEM)
+	serverCertPath := writeTestFile(t, dir, "server-cert.pem", serverCertPEM)
+	serverKeyPath := writeTestFile(t, dir, "server-key.pem", serverKeyPEM)
+
+	serverInfo := TLSInfo{CertFile: serverCertPath, KeyFile: serverKeyPath}
+	clientInfo := TLSInfo{TrustedCAFile: caPath, ServerName: "localhost"}
+
+	serverConf, err := serverInfo.ServerConfig()
+	if err != nil {
+		t.Fatalf("ServerConfig: %v", err)
+	}
+	clientConf, err := clientInfo.ClientConfig()
+	if err != nil {
+		t.Fatalf("ClientConfig: %v", err)
+	}
+
+	if clientErr, _ := handshake(clientConf, serverConf); clientErr == nil {
+		t.Fatal("expected client to reject an expired server certificate")
+	}
+}
+
+func TestPersistentSelfSignedCertIsReused(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	first, err := persistentSelfSignedCert()
+	if err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+	second, err := persistentSelfSignedCert()
+	if err != nil {
+		t.Fatalf("second call: %v", err)
+	}
+
+	if string(first.Certificate[0]) != string(second.Certificate[0]) {
+		t.Error("expected the second call to reuse the persisted certificate")
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+)
+
+// codeWords is a small, unambiguous word list used to print a
+// human-memorable PAKE code at server startup, the same way croc prints
+// a short code phrase instead of a certificate fingerprint.
+var codeWords = []string{
+	"anchor", "banjo", "canyon", "dapper", "ember", "falcon", "glacier", "harbor",
+	"ionic", "jigsaw", "kindle", "lantern", "meadow", "nimbus", "orbit", "pebble",
+	"quartz", "ripple", "saddle", "tundra", "umbra", "velvet", "willow", "xenon",
+	"yonder", "zephyr", "basalt", "cobalt", "drizzle", "ferment", "granite", "hollow",
+}
+
+// generateCode picks three words at random and joins them with hyphens,
+// e.g. "ember-quartz-hollow".
+func generateCode() string {
+	pick := func() string {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(codeWords))))
+		if err != nil {
+			// crypto/rand failing is effectively unrecoverable.
+			panic(err)
+		}
+		return codeWords[n.Int64()]
+	}
+	return fmt.Sprintf("%s-%s-%s", pick(), pick(), pick())
+}